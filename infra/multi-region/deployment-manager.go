@@ -155,6 +155,7 @@ type DeploymentManager struct {
 	failoverManager *FailoverManager
 	trafficRouter  *TrafficRouter
 	dataReplicator *DataReplicator
+	replicaRouter  *ReplicaRouter
 	mutex          sync.RWMutex
 	ctx            context.Context
 	cancel         context.CancelFunc
@@ -176,6 +177,7 @@ func NewDeploymentManager(redisClient *redis.Client, logger *zap.Logger) *Deploy
 	dm.failoverManager = NewFailoverManager(dm, logger)
 	dm.trafficRouter = NewTrafficRouter(dm, logger)
 	dm.dataReplicator = NewDataReplicator(dm, logger)
+	dm.replicaRouter = NewReplicaRouter(dm, DefaultReplicaRouterConfig(), logger)
 	
 	return dm
 }
@@ -303,6 +305,44 @@ func (dm *DeploymentManager) RouteTraffic(request *TrafficRequest) (*TrafficResp
 	return dm.trafficRouter.RouteRequest(request)
 }
 
+// RouteDatabaseQuery returns the database replica that should serve a query in the given region.
+// Writes always go to the primary; reads go to a healthy replica within maxStaleness.
+func (dm *DeploymentManager) RouteDatabaseQuery(regionName string, kind QueryKind, maxStaleness time.Duration) (*DatabaseReplica, error) {
+	return dm.replicaRouter.Route(regionName, kind, maxStaleness)
+}
+
+// ConfigureReplicaRouting sets the replication lag thresholds used for read routing
+func (dm *DeploymentManager) ConfigureReplicaRouting(config ReplicaRouterConfig) error {
+	return dm.replicaRouter.SetConfig(config)
+}
+
+// SetReplicaLagProbe installs the probe the health monitor uses to measure replica lag
+func (dm *DeploymentManager) SetReplicaLagProbe(probe ReplicaLagProbe) {
+	dm.replicaRouter.SetLagProbe(probe)
+}
+
+// ReportReplicaLag records the measured replication lag and health of a database replica
+func (dm *DeploymentManager) ReportReplicaLag(regionName, endpoint string, lag time.Duration, status string) error {
+	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
+	
+	region := dm.regions[regionName]
+	if region == nil {
+		return fmt.Errorf("region %s not found", regionName)
+	}
+	
+	for i := range region.Configuration.DatabaseReplicas {
+		replica := &region.Configuration.DatabaseReplicas[i]
+		if replica.Endpoint == endpoint {
+			replica.ReplicationLag = lag
+			replica.HealthStatus = status
+			return nil
+		}
+	}
+	
+	return fmt.Errorf("database replica %s not found in region %s", endpoint, regionName)
+}
+
 // GetRegionByDataResidency returns appropriate region based on data residency requirements
 func (dm *DeploymentManager) GetRegionByDataResidency(tenantId, dataType string) (*Region, error) {
 	// Get tenant's data residency requirements
@@ -332,6 +372,8 @@ func (dm *DeploymentManager) monitorRegionHealth() {
 			return
 		case <-ticker.C:
 			dm.performHealthChecks()
+			dm.replicaRouter.ProbeReplicas(dm.ctx)
+			dm.replicaRouter.RefreshReplicaHealth()
 		}
 	}
 }
@@ -0,0 +1,609 @@
+package multiregion
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	federationStepPath        = "/federation/steps"
+	federationResultPath      = "/federation/results"
+	federationSignatureHeader = "X-Federation-Signature"
+	federationMaxBody         = 10 << 20
+)
+
+// FederationRoutingPolicy selects how a remote cluster is chosen for a delegated step
+type FederationRoutingPolicy string
+
+const (
+	RoutingLowestLatency FederationRoutingPolicy = "lowest_latency"
+	RoutingPriority      FederationRoutingPolicy = "failover_priority"
+)
+
+// FederationConfig configures cross-cluster step delegation
+type FederationConfig struct {
+	LocalRegion    string                  `json:"local_region"`
+	SharedSecret   []byte                  `json:"-"`
+	MaxClockSkew   time.Duration           `json:"max_clock_skew"`
+	RequestTimeout time.Duration           `json:"request_timeout"`
+	ResultTimeout  time.Duration           `json:"result_timeout"`
+	RoutingPolicy  FederationRoutingPolicy `json:"routing_policy"`
+
+	// Result callbacks get their own budget so a slow executor cannot starve delivery
+	CallbackAttempts int           `json:"callback_attempts"`
+	CallbackBackoff  time.Duration `json:"callback_backoff"` // doubled after each failed attempt
+}
+
+// FederatedStepPolicy mirrors the engine's NodePolicy so the remote cluster runs the step as configured
+type FederatedStepPolicy struct {
+	TimeoutSeconds int               `json:"timeout_seconds"`
+	RetryCount     int               `json:"retry_count"`
+	RetryStrategy  string            `json:"retry_strategy"`
+	AllowedDomains []string          `json:"allowed_domains"`
+	ResourceLimits map[string]string `json:"resource_limits"`
+}
+
+// FederatedStepRequest is a step execution request sent to another cluster.
+// The HMAC signature covers the exact request body and travels in the X-Federation-Signature header.
+type FederatedStepRequest struct {
+	RequestID    string               `json:"request_id"`
+	ExecutionID  string               `json:"execution_id"`
+	StepID       string               `json:"step_id"`
+	NodeID       string               `json:"node_id"`
+	NodeType     string               `json:"node_type"`
+	Parameters   map[string]string    `json:"parameters"`
+	Policy       *FederatedStepPolicy `json:"policy,omitempty"`
+	TenantId     string               `json:"tenant_id"`
+	DataType     string               `json:"data_type"`
+	TargetRegion string               `json:"target_region"`
+	SourceRegion string               `json:"source_region"`
+	CallbackURL  string               `json:"callback_url"`
+	Input        json.RawMessage      `json:"input"`
+	IssuedAt     time.Time            `json:"issued_at"`
+}
+
+// FederatedStepResult is the result a remote cluster reports back for a delegated step, signed like requests
+type FederatedStepResult struct {
+	RequestID   string          `json:"request_id"`
+	ExecutionID string          `json:"execution_id"`
+	StepID      string          `json:"step_id"`
+	Region      string          `json:"region"`
+	Success     bool            `json:"success"`
+	Output      json.RawMessage `json:"output"`
+	Error       string          `json:"error"`
+	Duration    time.Duration   `json:"duration"`
+	CompletedAt time.Time       `json:"completed_at"`
+}
+
+// FederatedStepExecutor runs a delegated step in the local cluster
+type FederatedStepExecutor func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error)
+
+// FederationManager delegates steps to other clusters and serves delegated steps from them
+type FederationManager struct {
+	manager    *DeploymentManager
+	logger     *zap.Logger
+	config     FederationConfig
+	executor   FederatedStepExecutor
+	httpClient *http.Client
+	pending    map[string]*pendingDelegation
+	seen       map[string]time.Time // accepted request IDs and when they may be forgotten
+	mutex      sync.Mutex
+}
+
+// pendingDelegation tracks a delegated step awaiting its result callback
+type pendingDelegation struct {
+	resultCh     chan *FederatedStepResult
+	targetRegion string
+	executionID  string
+	stepID       string
+}
+
+// NewFederationManager creates a new federation manager
+func NewFederationManager(dm *DeploymentManager, config FederationConfig, executor FederatedStepExecutor, logger *zap.Logger) (*FederationManager, error) {
+	if config.LocalRegion == "" {
+		return nil, fmt.Errorf("federation local region is required")
+	}
+	if len(config.SharedSecret) == 0 {
+		return nil, fmt.Errorf("federation shared secret is required")
+	}
+	if config.MaxClockSkew == 0 {
+		config.MaxClockSkew = 5 * time.Minute
+	}
+	if config.RequestTimeout == 0 {
+		config.RequestTimeout = 10 * time.Second
+	}
+	if config.ResultTimeout == 0 {
+		config.ResultTimeout = 5 * time.Minute
+	}
+	if config.RoutingPolicy == "" {
+		config.RoutingPolicy = RoutingLowestLatency
+	}
+	if config.CallbackAttempts == 0 {
+		config.CallbackAttempts = 5
+	}
+	if config.CallbackBackoff == 0 {
+		config.CallbackBackoff = 500 * time.Millisecond
+	}
+
+	return &FederationManager{
+		manager:    dm,
+		logger:     logger,
+		config:     config,
+		executor:   executor,
+		httpClient: &http.Client{Timeout: config.RequestTimeout},
+		pending:    make(map[string]*pendingDelegation),
+		seen:       make(map[string]time.Time),
+	}, nil
+}
+
+// Handler returns the HTTP handler serving federation endpoints
+func (fm *FederationManager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(federationStepPath, fm.handleStepRequest)
+	mux.HandleFunc(federationResultPath, fm.handleStepResult)
+	return mux
+}
+
+// SelectRegion picks the remote region that should execute a delegated step.
+// Explicit targets are held to the same residency and eligibility rules as routed ones.
+func (fm *FederationManager) SelectRegion(req *FederatedStepRequest) (*Region, error) {
+	policy, err := fm.manager.getTenantDataResidencyPolicy(req.TenantId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant residency policy: %w", err)
+	}
+
+	fm.manager.mutex.RLock()
+	defer fm.manager.mutex.RUnlock()
+
+	if req.TargetRegion != "" {
+		region := fm.manager.regions[req.TargetRegion]
+		if region == nil {
+			return nil, fmt.Errorf("target region %s not found", req.TargetRegion)
+		}
+		if region.Name == fm.config.LocalRegion || !fm.isEligible(region) {
+			return nil, fmt.Errorf("target region %s is not available for federation", req.TargetRegion)
+		}
+		if !fm.manager.matchesResidencyPolicy(region, policy, req.DataType) {
+			return nil, fmt.Errorf("target region %s violates data residency policy for tenant %s", req.TargetRegion, req.TenantId)
+		}
+		return region, nil
+	}
+
+	var best *Region
+	for _, region := range fm.manager.regions {
+		if region.Name == fm.config.LocalRegion || !fm.isEligible(region) {
+			continue
+		}
+		if !fm.manager.matchesResidencyPolicy(region, policy, req.DataType) {
+			continue
+		}
+		if best == nil || fm.prefer(region, best) {
+			best = region
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no federated region available for tenant %s", req.TenantId)
+	}
+	return best, nil
+}
+
+// Delegate sends a step to a remote cluster and waits for its result callback
+func (fm *FederationManager) Delegate(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+	if req.RequestID == "" {
+		requestID, err := newFederationRequestID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate federated request ID: %w", err)
+		}
+		req.RequestID = requestID
+	}
+
+	region, err := fm.SelectRegion(req)
+	if err != nil {
+		return nil, err
+	}
+
+	req.TargetRegion = region.Name
+	req.SourceRegion = fm.config.LocalRegion
+	req.IssuedAt = time.Now().UTC()
+	if req.CallbackURL == "" {
+		local := fm.manager.GetRegion(fm.config.LocalRegion)
+		if local == nil || local.Endpoints.InternalLB == "" {
+			return nil, fmt.Errorf("local region %s has no internal endpoint for result callbacks", fm.config.LocalRegion)
+		}
+		req.CallbackURL = local.Endpoints.InternalLB + federationResultPath
+	}
+
+	pending := &pendingDelegation{
+		resultCh:     make(chan *FederatedStepResult, 1),
+		targetRegion: region.Name,
+		executionID:  req.ExecutionID,
+		stepID:       req.StepID,
+	}
+
+	fm.mutex.Lock()
+	if _, exists := fm.pending[req.RequestID]; exists {
+		fm.mutex.Unlock()
+		return nil, fmt.Errorf("federated request %s is already pending", req.RequestID)
+	}
+	fm.pending[req.RequestID] = pending
+	fm.mutex.Unlock()
+	defer func() {
+		fm.mutex.Lock()
+		delete(fm.pending, req.RequestID)
+		fm.mutex.Unlock()
+	}()
+
+	if err := fm.post(ctx, region.Endpoints.InternalLB+federationStepPath, req); err != nil {
+		return nil, fmt.Errorf("failed to delegate step %s to region %s: %w", req.StepID, region.Name, err)
+	}
+
+	fm.logger.Info("Step delegated to federated region",
+		zap.String("execution_id", req.ExecutionID),
+		zap.String("step_id", req.StepID),
+		zap.String("target_region", region.Name),
+		zap.String("routing_policy", string(fm.config.RoutingPolicy)))
+
+	timer := time.NewTimer(fm.config.ResultTimeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-pending.resultCh:
+		return result, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out waiting for result of step %s from region %s", req.StepID, region.Name)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleStepRequest accepts a delegated step, acknowledges it, and reports the result asynchronously
+func (fm *FederationManager) handleStepRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, federationMaxBody))
+	if err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := fm.verifySignature(body, r.Header.Get(federationSignatureHeader)); err != nil {
+		fm.logger.Warn("Rejected federated step request",
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.Error(err))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req FederatedStepRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := fm.verifyRequest(&req); err != nil {
+		fm.logger.Warn("Rejected federated step request",
+			zap.String("request_id", req.RequestID),
+			zap.String("source_region", req.SourceRegion),
+			zap.Error(err))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if fm.executor == nil {
+		http.Error(w, "federated execution is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !fm.markSeen(&req) {
+		fm.logger.Warn("Rejected replayed federated step request",
+			zap.String("request_id", req.RequestID),
+			zap.String("source_region", req.SourceRegion))
+		http.Error(w, "duplicate request", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	go fm.executeDelegated(req)
+}
+
+// executeDelegated runs a delegated step and posts its result back to the source cluster
+func (fm *FederationManager) executeDelegated(req FederatedStepRequest) {
+	timeout := fm.config.ResultTimeout
+	if req.Policy != nil && req.Policy.TimeoutSeconds > 0 {
+		if policyTimeout := time.Duration(req.Policy.TimeoutSeconds) * time.Second; policyTimeout < timeout {
+			timeout = policyTimeout
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(fm.manager.ctx, timeout)
+	start := time.Now()
+	result, err := fm.runExecutor(ctx, &req)
+	cancel()
+	if err != nil || result == nil {
+		message := "executor returned no result"
+		if err != nil {
+			message = err.Error()
+		}
+		result = &FederatedStepResult{Success: false, Error: message}
+	}
+
+	result.RequestID = req.RequestID
+	result.ExecutionID = req.ExecutionID
+	result.StepID = req.StepID
+	result.Region = fm.config.LocalRegion
+	result.Duration = time.Since(start)
+	result.CompletedAt = time.Now().UTC()
+
+	if err := fm.deliverResult(req.CallbackURL, result); err != nil {
+		fm.logger.Error("Failed to deliver federated step result",
+			zap.String("request_id", req.RequestID),
+			zap.String("source_region", req.SourceRegion),
+			zap.Int("attempts", fm.config.CallbackAttempts),
+			zap.Error(err))
+	}
+}
+
+// deliverResult posts a result to the source cluster, retrying with exponential backoff.
+// Client errors other than 408/429 are not retried since the source has rejected the result.
+func (fm *FederationManager) deliverResult(callbackURL string, result *FederatedStepResult) error {
+	backoff := fm.config.CallbackBackoff
+
+	var err error
+	for attempt := 1; attempt <= fm.config.CallbackAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(fm.manager.ctx, fm.config.RequestTimeout)
+		err = fm.post(ctx, callbackURL, result)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *federationStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			return err
+		}
+
+		if attempt == fm.config.CallbackAttempts {
+			break
+		}
+
+		fm.logger.Warn("Retrying federated step result delivery",
+			zap.String("request_id", result.RequestID),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-time.After(backoff):
+		case <-fm.manager.ctx.Done():
+			return fm.manager.ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	return err
+}
+
+// runExecutor invokes the step executor, converting a panic into an error so it cannot take down the process
+func (fm *FederationManager) runExecutor(ctx context.Context, req *FederatedStepRequest) (result *FederatedStepResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fm.logger.Error("Federated step executor panicked",
+				zap.String("request_id", req.RequestID),
+				zap.String("step_id", req.StepID),
+				zap.Any("panic", r),
+				zap.String("stack", string(debug.Stack())))
+			result = nil
+			err = fmt.Errorf("executor panic: %v", r)
+		}
+	}()
+
+	return fm.executor(ctx, req)
+}
+
+// markSeen records an accepted request ID, returning false if it was already accepted.
+// Entries are kept until the request could no longer pass the clock skew check.
+func (fm *FederationManager) markSeen(req *FederatedStepRequest) bool {
+	now := time.Now()
+
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+
+	for requestID, expiresAt := range fm.seen {
+		if now.After(expiresAt) {
+			delete(fm.seen, requestID)
+		}
+	}
+
+	if _, exists := fm.seen[req.RequestID]; exists {
+		return false
+	}
+	fm.seen[req.RequestID] = req.IssuedAt.Add(fm.config.MaxClockSkew)
+	return true
+}
+
+// handleStepResult receives the result of a step this cluster delegated
+func (fm *FederationManager) handleStepResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, federationMaxBody))
+	if err != nil {
+		http.Error(w, "invalid result body", http.StatusBadRequest)
+		return
+	}
+
+	if err := fm.verifySignature(body, r.Header.Get(federationSignatureHeader)); err != nil {
+		fm.logger.Warn("Rejected federated step result",
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.Error(err))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var result FederatedStepResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		http.Error(w, "invalid result body", http.StatusBadRequest)
+		return
+	}
+
+	fm.mutex.Lock()
+	pending, ok := fm.pending[result.RequestID]
+	fm.mutex.Unlock()
+
+	if !ok {
+		http.Error(w, "unknown request", http.StatusNotFound)
+		return
+	}
+
+	if result.Region != pending.targetRegion || result.ExecutionID != pending.executionID || result.StepID != pending.stepID {
+		fm.logger.Warn("Rejected federated step result for mismatched delegation",
+			zap.String("request_id", result.RequestID),
+			zap.String("region", result.Region),
+			zap.String("expected_region", pending.targetRegion))
+		http.Error(w, "result does not match delegated step", http.StatusForbidden)
+		return
+	}
+
+	select {
+	case pending.resultCh <- &result:
+	default:
+		// Duplicate delivery; the first result already won
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isEligible reports whether a region can accept federated work
+func (fm *FederationManager) isEligible(region *Region) bool {
+	if region.Status != StatusActive && region.Status != StatusStandby {
+		return false
+	}
+	if region.HealthStatus.Overall == "unhealthy" {
+		return false
+	}
+	return region.Endpoints.InternalLB != ""
+}
+
+// prefer reports whether candidate beats current under the configured routing policy
+func (fm *FederationManager) prefer(candidate, current *Region) bool {
+	switch fm.config.RoutingPolicy {
+	case RoutingPriority:
+		return candidate.FailoverPriority > current.FailoverPriority
+	default:
+		candidateLatency, candidateMeasured := engineResponseTime(candidate)
+		currentLatency, currentMeasured := engineResponseTime(current)
+		if candidateMeasured != currentMeasured {
+			return candidateMeasured
+		}
+		return candidateLatency < currentLatency
+	}
+}
+
+// engineResponseTime returns the engine gRPC response time from the region's last health check
+func engineResponseTime(region *Region) (time.Duration, bool) {
+	engine, ok := region.HealthStatus.Services["engine"]
+	if !ok || engine.Status != "healthy" {
+		return 0, false
+	}
+	return engine.ResponseTime, true
+}
+
+// federationStatusError reports a non-success HTTP status from a federation endpoint
+type federationStatusError struct {
+	StatusCode int
+}
+
+func (e *federationStatusError) Error() string {
+	return fmt.Sprintf("federation endpoint returned status %d", e.StatusCode)
+}
+
+// retryable reports whether the request may succeed if sent again
+func (e *federationStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// post sends a signed JSON payload to a federation endpoint
+func (fm *FederationManager) post(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(federationSignatureHeader, fm.sign(body))
+
+	resp, err := fm.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &federationStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// verifyRequest checks the freshness and addressing of an incoming request whose signature has been verified
+func (fm *FederationManager) verifyRequest(req *FederatedStepRequest) error {
+	if req.TargetRegion != fm.config.LocalRegion {
+		return fmt.Errorf("request addressed to region %s", req.TargetRegion)
+	}
+	if skew := time.Since(req.IssuedAt); skew > fm.config.MaxClockSkew || skew < -fm.config.MaxClockSkew {
+		return fmt.Errorf("request issued outside allowed clock skew")
+	}
+	return nil
+}
+
+// verifySignature checks a hex-encoded HMAC-SHA256 signature against the exact bytes received
+func (fm *FederationManager) verifySignature(body []byte, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("missing signature")
+	}
+	if !hmac.Equal([]byte(signature), []byte(fm.sign(body))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// sign returns the hex-encoded HMAC-SHA256 of a message body
+func (fm *FederationManager) sign(body []byte) string {
+	mac := hmac.New(sha256.New, fm.config.SharedSecret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newFederationRequestID returns a random identifier for a delegated step
+func newFederationRequestID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
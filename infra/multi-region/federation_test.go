@@ -0,0 +1,436 @@
+package multiregion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var testFederationSecret = []byte("federation-test-secret")
+
+// federationCluster is one side of a federation test: a manager served over httptest
+type federationCluster struct {
+	federation *FederationManager
+	server     *httptest.Server
+}
+
+// newFederationTestManager returns an empty manager whose background context is cancelled on cleanup
+func newFederationTestManager(t *testing.T) *DeploymentManager {
+	t.Helper()
+
+	dm := NewDeploymentManager(nil, zap.NewNop())
+	t.Cleanup(dm.cancel)
+	return dm
+}
+
+func addFederationRegion(dm *DeploymentManager, name, endpoint string, engineLatency time.Duration) *Region {
+	region := &Region{
+		Name:      name,
+		Status:    StatusActive,
+		Endpoints: RegionEndpoints{InternalLB: endpoint},
+		HealthStatus: HealthStatus{
+			Overall: "healthy",
+			Services: map[string]ServiceHealth{
+				"engine": {Status: "healthy", ResponseTime: engineLatency},
+			},
+		},
+	}
+	dm.regions[name] = region
+	return region
+}
+
+func newFederationCluster(t *testing.T, dm *DeploymentManager, localRegion string, executor FederatedStepExecutor) *federationCluster {
+	t.Helper()
+
+	fm, err := NewFederationManager(dm, FederationConfig{
+		LocalRegion:   localRegion,
+		SharedSecret:  testFederationSecret,
+		ResultTimeout: 5 * time.Second,
+	}, executor, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewFederationManager: %v", err)
+	}
+
+	server := httptest.NewServer(fm.Handler())
+	t.Cleanup(server.Close)
+	return &federationCluster{federation: fm, server: server}
+}
+
+func newStepRequest(fm *FederationManager, targetRegion string) *FederatedStepRequest {
+	return &FederatedStepRequest{
+		RequestID:    "req-1",
+		ExecutionID:  "exec-1",
+		StepID:       "step-1",
+		NodeType:     "http-request",
+		TenantId:     "tenant-1",
+		TargetRegion: targetRegion,
+		SourceRegion: fm.config.LocalRegion,
+		Input:        json.RawMessage(`{"url": "https://example.com", "items": [1, 2, 3]}`),
+		IssuedAt:     time.Now().UTC(),
+	}
+}
+
+// postSigned sends a raw body signed by fm and returns the response status and body
+func postSigned(t *testing.T, fm *FederationManager, url string, body []byte) (int, string) {
+	t.Helper()
+
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if fm != nil {
+		httpReq.Header.Set(federationSignatureHeader, fm.sign(body))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+func postJSON(t *testing.T, fm *FederationManager, url string, payload interface{}) int {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := postSigned(t, fm, url, body)
+	return status
+}
+
+func TestFederationSignatureRoundTrip(t *testing.T) {
+	dm := newFederationTestManager(t)
+	source, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-west-2", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+	target, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-east-1", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+	other, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-east-1", SharedSecret: []byte("other-secret")}, nil, zap.NewNop())
+
+	body, err := json.Marshal(newStepRequest(source, "us-east-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := source.sign(body)
+
+	if err := target.verifySignature(body, signature); err != nil {
+		t.Fatalf("valid body rejected: %v", err)
+	}
+	if err := target.verifySignature(body, ""); err == nil {
+		t.Fatal("unsigned body accepted")
+	}
+	if err := other.verifySignature(body, signature); err == nil {
+		t.Fatal("body signed with a different secret accepted")
+	}
+
+	tampered := bytes.Replace(body, []byte(`"step-1"`), []byte(`"step-2"`), 1)
+	if err := target.verifySignature(tampered, signature); err == nil {
+		t.Fatal("tampered body accepted")
+	}
+
+	// A field added by a newer cluster is covered by the signature even though this version drops it on decode
+	extended := append(bytes.TrimSuffix(body, []byte("}")), []byte(`,"added_in_next_release":true}`)...)
+	if err := target.verifySignature(extended, source.sign(extended)); err != nil {
+		t.Fatalf("body with unknown field rejected: %v", err)
+	}
+
+	var decoded FederatedStepRequest
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.verifyRequest(&decoded); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+	if err := source.verifyRequest(&decoded); err == nil {
+		t.Fatal("request addressed to another region accepted")
+	}
+	decoded.IssuedAt = time.Now().UTC().Add(-time.Hour)
+	if err := target.verifyRequest(&decoded); err == nil {
+		t.Fatal("request outside clock skew accepted")
+	}
+}
+
+func TestFederationRejectsUnauthenticatedRequests(t *testing.T) {
+	dm := newFederationTestManager(t)
+	remote := newFederationCluster(t, dm, "us-east-1", func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+		return &FederatedStepResult{Success: true}, nil
+	})
+	source, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-west-2", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+	forger, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-west-2", SharedSecret: []byte("other-secret")}, nil, zap.NewNop())
+
+	misaddressed, _ := json.Marshal(newStepRequest(source, "eu-west-1"))
+	forged, _ := json.Marshal(newStepRequest(forger, "us-east-1"))
+
+	cases := []struct {
+		name   string
+		signer *FederationManager
+		body   []byte
+	}{
+		{"unsigned", nil, forged},
+		{"wrong secret", forger, forged},
+		{"misaddressed", source, misaddressed},
+	}
+	for _, tc := range cases {
+		status, body := postSigned(t, tc.signer, remote.server.URL+federationStepPath, tc.body)
+		if status != http.StatusUnauthorized {
+			t.Fatalf("%s: status = %d, want %d", tc.name, status, http.StatusUnauthorized)
+		}
+		if strings.TrimSpace(body) != "unauthorized" {
+			t.Fatalf("%s: response leaked verification detail: %q", tc.name, body)
+		}
+	}
+}
+
+func TestFederationDelegateEndToEnd(t *testing.T) {
+	dm := newFederationTestManager(t)
+
+	var executions int32
+	var received atomic.Value
+	remote := newFederationCluster(t, dm, "us-east-1", func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+		atomic.AddInt32(&executions, 1)
+		received.Store(*req)
+		return &FederatedStepResult{Success: true, Output: req.Input}, nil
+	})
+	local := newFederationCluster(t, dm, "us-west-2", nil)
+
+	addFederationRegion(dm, "us-west-2", local.server.URL, time.Millisecond)
+	addFederationRegion(dm, "us-east-1", remote.server.URL, 20*time.Millisecond)
+
+	req := &FederatedStepRequest{
+		ExecutionID: "exec-1",
+		StepID:      "step-1",
+		NodeID:      "node-1",
+		NodeType:    "http-request",
+		Parameters:  map[string]string{"method": "GET"},
+		Policy:      &FederatedStepPolicy{TimeoutSeconds: 30, RetryCount: 3, RetryStrategy: "exponential"},
+		TenantId:    "tenant-1",
+		Input:       json.RawMessage(`{"value":42}`),
+	}
+	result, err := local.federation.Delegate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Delegate: %v", err)
+	}
+
+	if req.RequestID == "" {
+		t.Fatal("Delegate did not assign a request ID")
+	}
+	if !result.Success || result.Region != "us-east-1" || string(result.Output) != `{"value":42}` {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.ExecutionID != "exec-1" || result.StepID != "step-1" {
+		t.Fatalf("result not bound to delegated step: %+v", result)
+	}
+	if got := atomic.LoadInt32(&executions); got != 1 {
+		t.Fatalf("executor ran %d times, want 1", got)
+	}
+
+	remoteReq := received.Load().(FederatedStepRequest)
+	if remoteReq.NodeID != "node-1" || remoteReq.Parameters["method"] != "GET" {
+		t.Fatalf("step configuration not carried to remote cluster: %+v", remoteReq)
+	}
+	if remoteReq.Policy == nil || remoteReq.Policy.TimeoutSeconds != 30 || remoteReq.Policy.RetryCount != 3 {
+		t.Fatalf("step policy not carried to remote cluster: %+v", remoteReq.Policy)
+	}
+}
+
+func TestFederationRetriesResultCallback(t *testing.T) {
+	dm := newFederationTestManager(t)
+	fm, _ := NewFederationManager(dm, FederationConfig{
+		LocalRegion:      "us-east-1",
+		SharedSecret:     testFederationSecret,
+		CallbackAttempts: 3,
+		CallbackBackoff:  time.Millisecond,
+	}, nil, zap.NewNop())
+
+	var attempts int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(flaky.Close)
+
+	if err := fm.deliverResult(flaky.URL, &FederatedStepResult{RequestID: "req-1"}); err != nil {
+		t.Fatalf("deliverResult: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("callback attempted %d times, want 3", got)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	t.Cleanup(rejecting.Close)
+
+	if err := fm.deliverResult(rejecting.URL, &FederatedStepResult{RequestID: "req-1"}); err == nil {
+		t.Fatal("expected error for rejected callback")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("rejected callback attempted %d times, want 1", got)
+	}
+}
+
+func TestFederationRejectsReplayedRequest(t *testing.T) {
+	dm := newFederationTestManager(t)
+
+	var executions int32
+	remote := newFederationCluster(t, dm, "us-east-1", func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+		atomic.AddInt32(&executions, 1)
+		return &FederatedStepResult{Success: true}, nil
+	})
+	addFederationRegion(dm, "us-east-1", remote.server.URL, time.Millisecond)
+
+	source, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-west-2", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+	req := newStepRequest(source, "us-east-1")
+	req.CallbackURL = remote.server.URL + federationResultPath
+
+	if status := postJSON(t, source, remote.server.URL+federationStepPath, req); status != http.StatusAccepted {
+		t.Fatalf("first request status = %d, want %d", status, http.StatusAccepted)
+	}
+	if status := postJSON(t, source, remote.server.URL+federationStepPath, req); status != http.StatusConflict {
+		t.Fatalf("replayed request status = %d, want %d", status, http.StatusConflict)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&executions) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(&executions); got != 1 {
+		t.Fatalf("executor ran %d times, want 1", got)
+	}
+}
+
+func TestFederationExecutorFailuresReturnFailedResult(t *testing.T) {
+	executors := map[string]FederatedStepExecutor{
+		"nil result": func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+			return nil, nil
+		},
+		"panic": func(ctx context.Context, req *FederatedStepRequest) (*FederatedStepResult, error) {
+			panic("executor bug")
+		},
+	}
+
+	for name, executor := range executors {
+		t.Run(name, func(t *testing.T) {
+			dm := newFederationTestManager(t)
+			remote := newFederationCluster(t, dm, "us-east-1", executor)
+			local := newFederationCluster(t, dm, "us-west-2", nil)
+			addFederationRegion(dm, "us-west-2", local.server.URL, time.Millisecond)
+			addFederationRegion(dm, "us-east-1", remote.server.URL, time.Millisecond)
+
+			result, err := local.federation.Delegate(context.Background(), &FederatedStepRequest{ExecutionID: "exec-1", StepID: "step-1"})
+			if err != nil {
+				t.Fatalf("Delegate: %v", err)
+			}
+			if result.Success || result.Error == "" {
+				t.Fatalf("expected failed result with error, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestFederationRejectsMismatchedResult(t *testing.T) {
+	dm := newFederationTestManager(t)
+	local := newFederationCluster(t, dm, "us-west-2", nil)
+
+	local.federation.pending["req-1"] = &pendingDelegation{
+		resultCh:     make(chan *FederatedStepResult, 1),
+		targetRegion: "us-east-1",
+		executionID:  "exec-1",
+		stepID:       "step-1",
+	}
+
+	signer, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "eu-west-1", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+	result := &FederatedStepResult{RequestID: "req-1", ExecutionID: "exec-1", StepID: "step-1", Region: "eu-west-1", Success: true}
+	if status := postJSON(t, signer, local.server.URL+federationResultPath, result); status != http.StatusForbidden {
+		t.Fatalf("mismatched region status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	result.Region = "us-east-1"
+	result.StepID = "step-2"
+	if status := postJSON(t, signer, local.server.URL+federationResultPath, result); status != http.StatusForbidden {
+		t.Fatalf("mismatched step status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	result.StepID = "step-1"
+	if status := postJSON(t, nil, local.server.URL+federationResultPath, result); status != http.StatusUnauthorized {
+		t.Fatalf("unsigned result status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := postJSON(t, signer, local.server.URL+federationResultPath, result); status != http.StatusNoContent {
+		t.Fatalf("matching result status = %d, want %d", status, http.StatusNoContent)
+	}
+}
+
+func TestFederationDelegateRejectsPendingRequestID(t *testing.T) {
+	dm := newFederationTestManager(t)
+	local := newFederationCluster(t, dm, "us-west-2", nil)
+	addFederationRegion(dm, "us-west-2", local.server.URL, time.Millisecond)
+	addFederationRegion(dm, "us-east-1", "http://127.0.0.1:0", time.Millisecond)
+
+	local.federation.pending["req-1"] = &pendingDelegation{resultCh: make(chan *FederatedStepResult, 1)}
+
+	if _, err := local.federation.Delegate(context.Background(), &FederatedStepRequest{RequestID: "req-1"}); err == nil {
+		t.Fatal("expected error for request ID already pending")
+	}
+	if _, ok := local.federation.pending["req-1"]; !ok {
+		t.Fatal("rejected delegation removed the existing pending entry")
+	}
+}
+
+func TestFederationSelectRegion(t *testing.T) {
+	dm := newFederationTestManager(t)
+	fm, _ := NewFederationManager(dm, FederationConfig{LocalRegion: "us-west-2", SharedSecret: testFederationSecret}, nil, zap.NewNop())
+
+	addFederationRegion(dm, "us-west-2", "http://us-west-2", time.Millisecond)
+	addFederationRegion(dm, "us-east-1", "http://us-east-1", 30*time.Millisecond)
+	fast := addFederationRegion(dm, "us-east-2", "http://us-east-2", 10*time.Millisecond)
+	fast.DataResidency.ComplianceZone = "public"
+	forbidden := addFederationRegion(dm, "eu-west-1", "http://eu-west-1", time.Millisecond)
+	forbidden.DataResidency.ComplianceZone = "gdpr"
+
+	region, err := fm.SelectRegion(&FederatedStepRequest{TenantId: "tenant-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region.Name != "us-east-2" {
+		t.Fatalf("selected %s, want lowest measured engine latency us-east-2", region.Name)
+	}
+
+	// A region without a measured engine response time ranks behind measured ones
+	delete(fast.HealthStatus.Services, "engine")
+	region, err = fm.SelectRegion(&FederatedStepRequest{TenantId: "tenant-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region.Name != "us-east-1" {
+		t.Fatalf("selected %s, want measured region us-east-1", region.Name)
+	}
+
+	if _, err := fm.SelectRegion(&FederatedStepRequest{TenantId: "tenant-1", TargetRegion: "eu-west-1"}); err == nil {
+		t.Fatal("explicit target violating residency policy accepted")
+	}
+	if _, err := fm.SelectRegion(&FederatedStepRequest{TenantId: "tenant-1", TargetRegion: "us-west-2"}); err == nil {
+		t.Fatal("explicit target equal to the local region accepted")
+	}
+
+	dm.regions["us-east-1"].HealthStatus.Overall = "unhealthy"
+	if _, err := fm.SelectRegion(&FederatedStepRequest{TenantId: "tenant-1", TargetRegion: "us-east-1"}); err == nil {
+		t.Fatal("explicit unhealthy target accepted")
+	}
+}
@@ -0,0 +1,278 @@
+package multiregion
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// QueryKind distinguishes statements that must hit the primary from those a replica can serve
+type QueryKind string
+
+const (
+	QueryWrite QueryKind = "write"
+	QueryRead  QueryKind = "read"
+)
+
+// ReplicaRouterConfig controls read/write splitting across a region's database replicas
+type ReplicaRouterConfig struct {
+	MaxReplicationLag time.Duration `json:"max_replication_lag"` // replicas lagging further are removed from the read pool
+	RecoveryLag       time.Duration `json:"recovery_lag"`        // lag a removed replica must drop below before rejoining
+	ProbeTimeout      time.Duration `json:"probe_timeout"`       // deadline for a single replica lag probe
+}
+
+// DefaultReplicaRouterConfig returns sensible defaults for replica routing
+func DefaultReplicaRouterConfig() ReplicaRouterConfig {
+	return ReplicaRouterConfig{
+		MaxReplicationLag: 5 * time.Second,
+		RecoveryLag:       2 * time.Second,
+		ProbeTimeout:      2 * time.Second,
+	}
+}
+
+// ReplicaLagProbe measures a read replica's replication lag, e.g. via now() - pg_last_xact_replay_timestamp()
+type ReplicaLagProbe func(ctx context.Context, replica DatabaseReplica) (time.Duration, error)
+
+// ReplicaRouter routes database queries to the primary or to healthy read replicas
+type ReplicaRouter struct {
+	manager *DeploymentManager
+	logger  *zap.Logger
+	config  ReplicaRouterConfig
+	probe   ReplicaLagProbe
+	evicted map[string]bool // keyed by replica endpoint
+	next    map[string]int  // round-robin cursor per region
+	mutex   sync.Mutex
+}
+
+// NewReplicaRouter creates a new replica router
+func NewReplicaRouter(dm *DeploymentManager, config ReplicaRouterConfig, logger *zap.Logger) *ReplicaRouter {
+	return &ReplicaRouter{
+		manager: dm,
+		logger:  logger,
+		config:  config,
+		evicted: make(map[string]bool),
+		next:    make(map[string]int),
+	}
+}
+
+// SetConfig replaces the router's staleness thresholds
+func (rr *ReplicaRouter) SetConfig(config ReplicaRouterConfig) error {
+	if config.MaxReplicationLag <= 0 {
+		return fmt.Errorf("max replication lag must be positive")
+	}
+	if config.RecoveryLag <= 0 || config.RecoveryLag > config.MaxReplicationLag {
+		return fmt.Errorf("recovery lag must be positive and no greater than max replication lag")
+	}
+	if config.ProbeTimeout < 0 {
+		return fmt.Errorf("probe timeout must not be negative")
+	}
+	if config.ProbeTimeout == 0 {
+		config.ProbeTimeout = DefaultReplicaRouterConfig().ProbeTimeout
+	}
+
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	rr.config = config
+	return nil
+}
+
+// SetLagProbe installs the probe used by ProbeReplicas to measure replica lag
+func (rr *ReplicaRouter) SetLagProbe(probe ReplicaLagProbe) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	rr.probe = probe
+}
+
+// ProbeReplicas measures every read replica concurrently with the installed probe and records the results.
+// Each probe runs under ProbeTimeout so a hung replica cannot stall the health monitor.
+// A failed or timed out probe marks the replica unhealthy so it leaves the read pool.
+func (rr *ReplicaRouter) ProbeReplicas(ctx context.Context) {
+	rr.mutex.Lock()
+	probe := rr.probe
+	timeout := rr.config.ProbeTimeout
+	rr.mutex.Unlock()
+
+	if probe == nil {
+		return
+	}
+
+	type target struct {
+		region  string
+		replica DatabaseReplica
+	}
+
+	rr.manager.mutex.RLock()
+	targets := make([]target, 0)
+	for _, region := range rr.manager.regions {
+		for _, replica := range region.Configuration.DatabaseReplicas {
+			if replica.Type == "read_replica" {
+				targets = append(targets, target{region: region.Name, replica: replica})
+			}
+		}
+	}
+	rr.manager.mutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t target) {
+			defer wg.Done()
+			rr.probeReplica(ctx, probe, timeout, t.region, t.replica)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// probeReplica runs a single lag probe under its own deadline and records the outcome
+func (rr *ReplicaRouter) probeReplica(ctx context.Context, probe ReplicaLagProbe, timeout time.Duration, regionName string, replica DatabaseReplica) {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		lag time.Duration
+		err error
+	}
+
+	// The probe runs in its own goroutine so one that ignores its context still cannot outlive the deadline
+	done := make(chan outcome, 1)
+	go func() {
+		lag, err := probe(probeCtx, replica)
+		done <- outcome{lag: lag, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-probeCtx.Done():
+		result.err = fmt.Errorf("lag probe did not complete: %w", probeCtx.Err())
+	}
+
+	lag := result.lag
+	status := "healthy"
+	if result.err != nil {
+		status = "unhealthy"
+		lag = replica.ReplicationLag
+		rr.logger.Warn("Database replica lag probe failed",
+			zap.String("region", regionName),
+			zap.String("endpoint", replica.Endpoint),
+			zap.Error(result.err))
+	}
+
+	if err := rr.manager.ReportReplicaLag(regionName, replica.Endpoint, lag, status); err != nil {
+		rr.logger.Warn("Failed to record database replica lag",
+			zap.String("region", regionName),
+			zap.String("endpoint", replica.Endpoint),
+			zap.Error(err))
+	}
+}
+
+// Route returns the replica that should serve a query of the given kind in a region.
+// Reads accept a per-query staleness bound; zero means the router's configured maximum.
+// Reads fall back to the primary when no replica satisfies the bound.
+// Replicas whose lag has never been measured by a probe or ReportReplicaLag are not read from.
+func (rr *ReplicaRouter) Route(regionName string, kind QueryKind, maxStaleness time.Duration) (*DatabaseReplica, error) {
+	region := rr.manager.GetRegion(regionName)
+	if region == nil {
+		return nil, fmt.Errorf("region %s not found", regionName)
+	}
+
+	rr.manager.mutex.RLock()
+	replicas := make([]DatabaseReplica, len(region.Configuration.DatabaseReplicas))
+	copy(replicas, region.Configuration.DatabaseReplicas)
+	rr.manager.mutex.RUnlock()
+
+	primary := findPrimaryReplica(replicas)
+	if kind == QueryWrite {
+		if primary == nil {
+			return nil, fmt.Errorf("region %s has no primary database", regionName)
+		}
+		return primary, nil
+	}
+
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	if maxStaleness <= 0 || maxStaleness > rr.config.MaxReplicationLag {
+		maxStaleness = rr.config.MaxReplicationLag
+	}
+
+	candidates := make([]DatabaseReplica, 0, len(replicas))
+	for _, replica := range replicas {
+		if replica.Type != "read_replica" || rr.evicted[replica.Endpoint] {
+			continue
+		}
+		if replica.HealthStatus != "healthy" {
+			continue
+		}
+		if replica.ReplicationLag > maxStaleness {
+			continue
+		}
+		candidates = append(candidates, replica)
+	}
+
+	if len(candidates) == 0 {
+		if primary == nil {
+			return nil, fmt.Errorf("region %s has no database satisfying staleness bound %s", regionName, maxStaleness)
+		}
+		return primary, nil
+	}
+
+	cursor := rr.next[regionName] % len(candidates)
+	rr.next[regionName] = cursor + 1
+	selected := candidates[cursor]
+	return &selected, nil
+}
+
+// RefreshReplicaHealth evicts lagging replicas from the read pool and restores recovered ones.
+// Hysteresis between MaxReplicationLag and RecoveryLag keeps a replica hovering near the limit from flapping.
+func (rr *ReplicaRouter) RefreshReplicaHealth() {
+	regions := rr.manager.GetAllRegions()
+
+	rr.manager.mutex.RLock()
+	defer rr.manager.mutex.RUnlock()
+
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+
+	for _, region := range regions {
+		for _, replica := range region.Configuration.DatabaseReplicas {
+			// Unmeasured replicas are already kept out of the read pool by Route
+			if replica.Type != "read_replica" || replica.HealthStatus == "" {
+				continue
+			}
+
+			unhealthy := replica.HealthStatus != "healthy"
+			switch {
+			case !rr.evicted[replica.Endpoint] && (unhealthy || replica.ReplicationLag > rr.config.MaxReplicationLag):
+				rr.evicted[replica.Endpoint] = true
+				rr.logger.Warn("Removing database replica from read pool",
+					zap.String("region", region.Name),
+					zap.String("endpoint", replica.Endpoint),
+					zap.Duration("replication_lag", replica.ReplicationLag),
+					zap.String("health_status", replica.HealthStatus))
+			case rr.evicted[replica.Endpoint] && !unhealthy && replica.ReplicationLag <= rr.config.RecoveryLag:
+				delete(rr.evicted, replica.Endpoint)
+				rr.logger.Info("Restoring database replica to read pool",
+					zap.String("region", region.Name),
+					zap.String("endpoint", replica.Endpoint),
+					zap.Duration("replication_lag", replica.ReplicationLag))
+			}
+		}
+	}
+}
+
+// findPrimaryReplica returns the primary database in a replica set, if any
+func findPrimaryReplica(replicas []DatabaseReplica) *DatabaseReplica {
+	for i := range replicas {
+		if replicas[i].Type == "primary" {
+			primary := replicas[i]
+			return &primary
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package multiregion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newReplicaTestManager returns a manager with one region holding a primary and two read replicas
+func newReplicaTestManager(t *testing.T) *DeploymentManager {
+	t.Helper()
+
+	dm := NewDeploymentManager(nil, zap.NewNop())
+	t.Cleanup(dm.cancel)
+
+	dm.regions["us-east-1"] = &Region{
+		Name:   "us-east-1",
+		Status: StatusActive,
+		Configuration: RegionConfiguration{
+			DatabaseReplicas: []DatabaseReplica{
+				{Type: "primary", Endpoint: "primary:5432"},
+				{Type: "read_replica", Endpoint: "replica-1:5432", HealthStatus: "healthy"},
+				{Type: "read_replica", Endpoint: "replica-2:5432", HealthStatus: "healthy"},
+			},
+		},
+	}
+	return dm
+}
+
+func routeEndpoint(t *testing.T, dm *DeploymentManager, kind QueryKind, maxStaleness time.Duration) string {
+	t.Helper()
+
+	replica, err := dm.RouteDatabaseQuery("us-east-1", kind, maxStaleness)
+	if err != nil {
+		t.Fatalf("RouteDatabaseQuery: %v", err)
+	}
+	return replica.Endpoint
+}
+
+func TestReplicaRouterWritesGoToPrimary(t *testing.T) {
+	dm := newReplicaTestManager(t)
+
+	for i := 0; i < 3; i++ {
+		if got := routeEndpoint(t, dm, QueryWrite, 0); got != "primary:5432" {
+			t.Fatalf("write routed to %s, want primary", got)
+		}
+	}
+}
+
+func TestReplicaRouterRoundRobinsReads(t *testing.T) {
+	dm := newReplicaTestManager(t)
+
+	want := []string{"replica-1:5432", "replica-2:5432", "replica-1:5432", "replica-2:5432"}
+	for i, endpoint := range want {
+		if got := routeEndpoint(t, dm, QueryRead, 0); got != endpoint {
+			t.Fatalf("read %d routed to %s, want %s", i, got, endpoint)
+		}
+	}
+}
+
+func TestReplicaRouterFallsBackToPrimary(t *testing.T) {
+	dm := newReplicaTestManager(t)
+
+	if err := dm.ReportReplicaLag("us-east-1", "replica-1:5432", time.Second, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.ReportReplicaLag("us-east-1", "replica-2:5432", 0, "unhealthy"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := routeEndpoint(t, dm, QueryRead, 500*time.Millisecond); got != "primary:5432" {
+		t.Fatalf("read with tight staleness bound routed to %s, want primary", got)
+	}
+	if got := routeEndpoint(t, dm, QueryRead, 2*time.Second); got != "replica-1:5432" {
+		t.Fatalf("read with loose staleness bound routed to %s, want replica-1", got)
+	}
+
+	if _, err := dm.RouteDatabaseQuery("eu-west-1", QueryRead, 0); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+}
+
+func TestReplicaRouterEvictionHysteresis(t *testing.T) {
+	dm := newReplicaTestManager(t)
+	if err := dm.ConfigureReplicaRouting(ReplicaRouterConfig{MaxReplicationLag: 5 * time.Second, RecoveryLag: 2 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	report := func(lag time.Duration) {
+		t.Helper()
+		if err := dm.ReportReplicaLag("us-east-1", "replica-1:5432", lag, "healthy"); err != nil {
+			t.Fatal(err)
+		}
+		dm.replicaRouter.RefreshReplicaHealth()
+	}
+	evicted := func() bool {
+		dm.replicaRouter.mutex.Lock()
+		defer dm.replicaRouter.mutex.Unlock()
+		return dm.replicaRouter.evicted["replica-1:5432"]
+	}
+
+	report(10 * time.Second)
+	if !evicted() {
+		t.Fatal("replica lagging past the limit was not evicted")
+	}
+
+	// Below the eviction limit but above the recovery lag, the replica stays out of the pool
+	report(3 * time.Second)
+	if !evicted() {
+		t.Fatal("replica rejoined before dropping below the recovery lag")
+	}
+	for i := 0; i < 4; i++ {
+		if got := routeEndpoint(t, dm, QueryRead, 0); got != "replica-2:5432" {
+			t.Fatalf("read routed to %s while replica-1 is evicted", got)
+		}
+	}
+
+	report(time.Second)
+	if evicted() {
+		t.Fatal("recovered replica was not restored")
+	}
+}
+
+func TestReplicaRouterProbeRecordsLag(t *testing.T) {
+	dm := newReplicaTestManager(t)
+	dm.SetReplicaLagProbe(func(ctx context.Context, replica DatabaseReplica) (time.Duration, error) {
+		if replica.Endpoint == "replica-2:5432" {
+			return 0, errors.New("connection refused")
+		}
+		return 7 * time.Second, nil
+	})
+
+	dm.replicaRouter.ProbeReplicas(context.Background())
+
+	replicas := dm.regions["us-east-1"].Configuration.DatabaseReplicas
+	if replicas[1].ReplicationLag != 7*time.Second || replicas[1].HealthStatus != "healthy" {
+		t.Fatalf("replica-1 = %+v, want 7s lag and healthy", replicas[1])
+	}
+	if replicas[2].HealthStatus != "unhealthy" {
+		t.Fatalf("replica-2 health = %q, want unhealthy after failed probe", replicas[2].HealthStatus)
+	}
+	if replicas[0].ReplicationLag != 0 || replicas[0].HealthStatus != "" {
+		t.Fatalf("primary was probed: %+v", replicas[0])
+	}
+}
+
+func TestReplicaRouterProbesConcurrentlyWithTimeout(t *testing.T) {
+	dm := newReplicaTestManager(t)
+	if err := dm.ConfigureReplicaRouting(ReplicaRouterConfig{MaxReplicationLag: 5 * time.Second, RecoveryLag: 2 * time.Second, ProbeTimeout: 50 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	dm.SetReplicaLagProbe(func(ctx context.Context, replica DatabaseReplica) (time.Duration, error) {
+		// Both probes hang past the timeout; one ignores its context entirely
+		if replica.Endpoint == "replica-1:5432" {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		<-release
+		return 0, nil
+	})
+
+	start := time.Now()
+	dm.replicaRouter.ProbeReplicas(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ProbeReplicas took %s with hung replicas, want bounded by the probe timeout", elapsed)
+	}
+
+	for _, replica := range dm.regions["us-east-1"].Configuration.DatabaseReplicas[1:] {
+		if replica.HealthStatus != "unhealthy" {
+			t.Fatalf("%s health = %q, want unhealthy after timed out probe", replica.Endpoint, replica.HealthStatus)
+		}
+	}
+}
+
+func TestReplicaRouterSkipsUnmeasuredReplicas(t *testing.T) {
+	dm := newReplicaTestManager(t)
+	for i := range dm.regions["us-east-1"].Configuration.DatabaseReplicas {
+		dm.regions["us-east-1"].Configuration.DatabaseReplicas[i].HealthStatus = ""
+	}
+
+	dm.replicaRouter.RefreshReplicaHealth()
+	if got := routeEndpoint(t, dm, QueryRead, 0); got != "primary:5432" {
+		t.Fatalf("read routed to unmeasured replica %s, want primary", got)
+	}
+
+	if err := dm.ReportReplicaLag("us-east-1", "replica-2:5432", time.Second, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := routeEndpoint(t, dm, QueryRead, 0); got != "replica-2:5432" {
+			t.Fatalf("read routed to %s, want the only measured replica", got)
+		}
+	}
+}
+
+func TestReportReplicaLagUnknownReplica(t *testing.T) {
+	dm := newReplicaTestManager(t)
+
+	if err := dm.ReportReplicaLag("us-east-1", "missing:5432", time.Second, "healthy"); err == nil {
+		t.Fatal("expected error for unknown replica")
+	}
+	if err := dm.ReportReplicaLag("eu-west-1", "replica-1:5432", time.Second, "healthy"); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+}
+
+func TestConfigureReplicaRoutingValidates(t *testing.T) {
+	dm := newReplicaTestManager(t)
+
+	invalid := []ReplicaRouterConfig{
+		{MaxReplicationLag: 0, RecoveryLag: time.Second},
+		{MaxReplicationLag: time.Second, RecoveryLag: 0},
+		{MaxReplicationLag: time.Second, RecoveryLag: 2 * time.Second},
+	}
+	for _, config := range invalid {
+		if err := dm.ConfigureReplicaRouting(config); err == nil {
+			t.Fatalf("expected error for config %+v", config)
+		}
+	}
+
+	if err := dm.ConfigureReplicaRouting(ReplicaRouterConfig{MaxReplicationLag: 10 * time.Second, RecoveryLag: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.ReportReplicaLag("us-east-1", "replica-1:5432", 8*time.Second, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.ReportReplicaLag("us-east-1", "replica-2:5432", 8*time.Second, "healthy"); err != nil {
+		t.Fatal(err)
+	}
+	if got := routeEndpoint(t, dm, QueryRead, 0); got == "primary:5432" {
+		t.Fatal("configured max lag was not applied")
+	}
+}
@@ -57,12 +57,18 @@ $generatedFiles = @(
     "../engine-go/proto/execution.pb.go",
     "../engine-go/proto/workflow.pb.go",
     "../engine-go/proto/health.pb.go",
+    "../engine-go/proto/streaming.pb.go",
+    "../engine-go/proto/version.pb.go",
     "../orchestrator-nest/proto/execution_pb.ts",
     "../orchestrator-nest/proto/workflow_pb.ts", 
     "../orchestrator-nest/proto/health_pb.ts",
+    "../orchestrator-nest/proto/streaming_pb.ts",
+    "../orchestrator-nest/proto/version_pb.ts",
     "../node-runner-js/proto/execution_pb.ts",
     "../node-runner-js/proto/workflow_pb.ts",
-    "../node-runner-js/proto/health_pb.ts"
+    "../node-runner-js/proto/health_pb.ts",
+    "../node-runner-js/proto/streaming_pb.ts",
+    "../node-runner-js/proto/version_pb.ts"
 )
 
 $missingFiles = @()
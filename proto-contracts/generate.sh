@@ -39,6 +39,25 @@ protoc --go_out=../engine-go/proto --go_opt=paths=source_relative \
        --go-grpc_out=../engine-go/proto --go-grpc_opt=paths=source_relative \
        node_runner.proto
 
+echo "Generating Go code for versioned packages..."
+
+# Versioned packages (n8nwork.*.v1) declare go_package paths under
+# github.com/n8n-work/proto-contracts/gen/go, so they are generated into that
+# module with the matching directory layout instead of ../engine-go/proto.
+GO_MODULE=github.com/n8n-work/proto-contracts/gen/go
+VERSIONED_PROTOS="execution.proto health.proto workflow.proto streaming.proto version.proto"
+
+mkdir -p gen/go
+
+protoc --go_out=gen/go --go_opt=module=$GO_MODULE \
+       --go-grpc_out=gen/go --go-grpc_opt=module=$GO_MODULE \
+       $VERSIONED_PROTOS
+
+if [ ! -f gen/go/go.mod ]; then
+  (cd gen/go && go mod init $GO_MODULE)
+fi
+(cd gen/go && go mod tidy)
+
 echo "Generating TypeScript code for orchestrator-nest..."
 
 # Generate TypeScript code for orchestrator-nest